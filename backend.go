@@ -0,0 +1,77 @@
+package dirwatch
+
+import (
+	"runtime"
+	"time"
+)
+
+//-----------------------------------------------------------------------------
+
+// BackendKind selects which backend implementation a Watcher uses to
+// discover filesystem changes.
+type BackendKind int
+
+const (
+	// BackendAuto picks a native recursive backend on platforms that
+	// provide one (Windows, macOS) and falls back to the inotify-style
+	// walk+add backend everywhere else.
+	BackendAuto BackendKind = iota
+	// BackendWalk always uses the walk+add fallback, regardless of OS.
+	BackendWalk
+	// BackendNative always uses the current platform's native recursive
+	// backend, falling back to BackendWalk where none is available.
+	BackendNative
+	// BackendPolling periodically stats and diffs a directory tree
+	// instead of relying on filesystem notifications, for filesystems
+	// that don't propagate them: NFS, SMB, overlayfs, Docker bind mounts.
+	BackendPolling
+)
+
+// backend abstracts how Watcher discovers filesystem changes, so OS-native
+// recursive notification (ReadDirectoryChangesW on Windows, FSEvents on
+// macOS) can replace walking the tree and calling Add for every
+// sub-directory. The inotify-based walkBackend keeps today's behavior.
+type backend interface {
+	// Add registers path with the backend. If recursive is true and
+	// Recursive reports true, everything under path is watched without
+	// the caller needing to walk it.
+	Add(path string, recursive bool) error
+	// Remove stops watching path.
+	Remove(path string) error
+	// Recursive reports whether Add(_, true) natively watches the whole
+	// subtree, so the caller can skip walking it itself.
+	Recursive() bool
+	// Events delivers filesystem events.
+	Events() <-chan Event
+	// Errors delivers backend errors.
+	Errors() <-chan error
+	// Close releases the backend's resources.
+	Close() error
+}
+
+// newBackend builds the backend selected by kind, auto-selecting based on
+// runtime.GOOS for BackendAuto. pollInterval and ignore only apply to
+// BackendPolling, which prunes its walk against ignore so it never
+// descends into an excluded directory.
+func newBackend(kind BackendKind, pollInterval time.Duration, ignore *ignoreMatcher) (backend, error) {
+	switch kind {
+	case BackendWalk:
+		return newWalkBackend()
+	case BackendNative:
+		if b, err := newNativeBackend(); err == nil {
+			return b, nil
+		}
+		return newWalkBackend()
+	case BackendPolling:
+		return newPollingBackend(pollInterval, ignore), nil
+	default:
+		if runtime.GOOS == "windows" || runtime.GOOS == "darwin" {
+			if b, err := newNativeBackend(); err == nil {
+				return b, nil
+			}
+		}
+		return newWalkBackend()
+	}
+}
+
+//-----------------------------------------------------------------------------
@@ -0,0 +1,139 @@
+//go:build windows || darwin
+
+package dirwatch
+
+import (
+	"path/filepath"
+	"sync"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/pkg/errors"
+	"github.com/rjeczalik/notify"
+)
+
+//-----------------------------------------------------------------------------
+
+// nativeBackend registers one native recursive watch per root instead of
+// walking the tree and adding every sub-directory: ReadDirectoryChangesW
+// with the watch-subtree flag on Windows, FSEvents on macOS, both through
+// rjeczalik/notify.
+type nativeBackend struct {
+	events chan Event
+	errs   chan error
+	done   chan struct{}
+
+	mu    sync.Mutex
+	roots map[string]*rootWatch
+}
+
+// rootWatch is one root's own notify channel and done signal, so Remove can
+// unregister that root alone: notify.Stop stops every path sharing a
+// channel, so a shared channel would make Remove(path) silently keep
+// delivering events for every other watched root.
+type rootWatch struct {
+	raw  chan notify.EventInfo
+	done chan struct{}
+}
+
+func newNativeBackend() (backend, error) {
+	b := &nativeBackend{
+		events: make(chan Event),
+		errs:   make(chan error),
+		done:   make(chan struct{}),
+		roots:  make(map[string]*rootWatch),
+	}
+	return b, nil
+}
+
+func (b *nativeBackend) Add(path string, recursive bool) error {
+	watch := path
+	if recursive {
+		watch = filepath.Join(path, "...")
+	}
+	rw := &rootWatch{
+		raw:  make(chan notify.EventInfo, 1),
+		done: make(chan struct{}),
+	}
+	if err := notify.Watch(watch, rw.raw, notify.All); err != nil {
+		return errors.WithStack(err)
+	}
+	b.mu.Lock()
+	b.roots[path] = rw
+	b.mu.Unlock()
+	go b.translate(rw)
+	return nil
+}
+
+// Remove stops watching path. Each root holds its own notify channel, so
+// stopping it here only unregisters path, leaving every other watched root
+// unaffected.
+func (b *nativeBackend) Remove(path string) error {
+	b.mu.Lock()
+	rw, ok := b.roots[path]
+	if ok {
+		delete(b.roots, path)
+	}
+	b.mu.Unlock()
+	if !ok {
+		return nil
+	}
+	notify.Stop(rw.raw)
+	close(rw.done)
+	return nil
+}
+
+func (b *nativeBackend) Recursive() bool { return true }
+
+func (b *nativeBackend) Events() <-chan Event { return b.events }
+
+func (b *nativeBackend) Errors() <-chan error { return b.errs }
+
+func (b *nativeBackend) Close() error {
+	close(b.done)
+
+	b.mu.Lock()
+	roots := b.roots
+	b.roots = nil
+	b.mu.Unlock()
+
+	for _, rw := range roots {
+		notify.Stop(rw.raw)
+	}
+	return nil
+}
+
+func (b *nativeBackend) translate(rw *rootWatch) {
+	for {
+		select {
+		case ev := <-rw.raw:
+			select {
+			case b.events <- Event{Name: ev.Path(), Op: nativeOp(ev.Event())}:
+			case <-b.done:
+				return
+			case <-rw.done:
+				return
+			}
+		case <-b.done:
+			return
+		case <-rw.done:
+			return
+		}
+	}
+}
+
+func nativeOp(e notify.Event) Op {
+	switch e {
+	case notify.Create:
+		return fsnotify.Create
+	case notify.Remove:
+		return fsnotify.Remove
+	case notify.Write:
+		return fsnotify.Write
+	case notify.Rename:
+		return fsnotify.Rename
+	default:
+		return fsnotify.Chmod
+	}
+}
+
+//-----------------------------------------------------------------------------
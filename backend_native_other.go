@@ -0,0 +1,18 @@
+//go:build !windows && !darwin
+
+package dirwatch
+
+import "github.com/pkg/errors"
+
+//-----------------------------------------------------------------------------
+
+// errNativeBackendUnsupported is returned by newNativeBackend on platforms
+// with no native recursive watch facility wired up, so newBackend can fall
+// back to walkBackend.
+var errNativeBackendUnsupported = errors.New("dirwatch: no native recursive backend on this platform")
+
+func newNativeBackend() (backend, error) {
+	return nil, errNativeBackendUnsupported
+}
+
+//-----------------------------------------------------------------------------
@@ -0,0 +1,214 @@
+package dirwatch
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/pkg/errors"
+)
+
+//-----------------------------------------------------------------------------
+
+// defaultPollInterval is used when Options.PollInterval is zero and the
+// polling backend is selected.
+const defaultPollInterval = time.Second
+
+// pollingBackend periodically stats a directory tree and diffs it against
+// the previous snapshot, synthesizing Create/Write/Remove/Rename/Chmod
+// events. It's the fallback for filesystems that don't propagate inotify/
+// FSEvents/ReadDirectoryChangesW events: NFS, SMB, overlayfs, and Docker
+// bind mounts.
+type pollingBackend struct {
+	interval time.Duration
+	ignore   *ignoreMatcher
+	events   chan Event
+	errs     chan error
+	done     chan struct{}
+
+	mu        sync.Mutex
+	roots     map[string]bool
+	snapshots map[string]map[string]os.FileInfo
+}
+
+func newPollingBackend(interval time.Duration, ignore *ignoreMatcher) backend {
+	if interval <= 0 {
+		interval = defaultPollInterval
+	}
+	b := &pollingBackend{
+		interval:  interval,
+		ignore:    ignore,
+		events:    make(chan Event),
+		errs:      make(chan error),
+		done:      make(chan struct{}),
+		roots:     make(map[string]bool),
+		snapshots: make(map[string]map[string]os.FileInfo),
+	}
+	go b.run()
+	return b
+}
+
+func (b *pollingBackend) Add(path string, recursive bool) error {
+	snap := b.snapshot(path, recursive)
+	b.mu.Lock()
+	b.roots[path] = recursive
+	b.snapshots[path] = snap
+	b.mu.Unlock()
+	return nil
+}
+
+func (b *pollingBackend) Remove(path string) error {
+	b.mu.Lock()
+	delete(b.roots, path)
+	delete(b.snapshots, path)
+	b.mu.Unlock()
+	return nil
+}
+
+func (b *pollingBackend) Recursive() bool { return true }
+
+func (b *pollingBackend) Events() <-chan Event { return b.events }
+
+func (b *pollingBackend) Errors() <-chan error { return b.errs }
+
+func (b *pollingBackend) Close() error {
+	close(b.done)
+	return nil
+}
+
+func (b *pollingBackend) run() {
+	ticker := time.NewTicker(b.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-b.done:
+			return
+		case <-ticker.C:
+			b.poll()
+		}
+	}
+}
+
+// poll snapshots and diffs every watched root. It copies the root set
+// before walking so a concurrent Add/Remove never races with the map
+// ranged over here, and only re-locks briefly to swap each root's stored
+// snapshot, so a filesystem walk never happens while holding the lock.
+func (b *pollingBackend) poll() {
+	b.mu.Lock()
+	roots := make(map[string]bool, len(b.roots))
+	for root, recursive := range b.roots {
+		roots[root] = recursive
+	}
+	b.mu.Unlock()
+
+	for root, recursive := range roots {
+		next := b.snapshot(root, recursive)
+
+		b.mu.Lock()
+		prev, ok := b.snapshots[root]
+		if ok {
+			b.snapshots[root] = next
+		}
+		b.mu.Unlock()
+
+		if ok {
+			b.diff(prev, next)
+		}
+	}
+}
+
+// snapshot walks root and returns every entry under it, keyed by absolute
+// path. Non-recursive roots only see their immediate children. Entries
+// excluded by the ignore matcher are skipped entirely, pruning the walk at
+// an excluded directory instead of descending into it.
+func (b *pollingBackend) snapshot(root string, recursive bool) map[string]os.FileInfo {
+	found := make(map[string]os.FileInfo)
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		if path == root {
+			return nil
+		}
+		if b.ignore != nil {
+			rel, err := filepath.Rel(root, path)
+			if err == nil && b.ignore.excluded(rel, info.IsDir()) {
+				if info.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+		}
+		found[path] = info
+		if info.IsDir() && !recursive {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	if err != nil {
+		select {
+		case b.errs <- errors.WithStack(err):
+		case <-b.done:
+		}
+	}
+	return found
+}
+
+// diff compares two snapshots of the same root and emits the events the
+// differences imply, matching removed+created entries with identical size
+// and mtime within the same tick as renames.
+func (b *pollingBackend) diff(prev, next map[string]os.FileInfo) {
+	created := make(map[string]os.FileInfo)
+	for path, info := range next {
+		old, ok := prev[path]
+		if !ok {
+			created[path] = info
+			continue
+		}
+		switch {
+		case old.Mode() != info.Mode():
+			b.emit(Event{Name: path, Op: fsnotify.Chmod})
+		case old.ModTime() != info.ModTime() || old.Size() != info.Size():
+			b.emit(Event{Name: path, Op: fsnotify.Write})
+		}
+	}
+
+	for path, info := range prev {
+		if _, ok := next[path]; ok {
+			continue
+		}
+		if renamedTo, ok := b.matchRename(info, created); ok {
+			delete(created, renamedTo)
+			b.emit(Event{Name: path, Op: fsnotify.Rename})
+			b.emit(Event{Name: renamedTo, Op: fsnotify.Create})
+			continue
+		}
+		b.emit(Event{Name: path, Op: fsnotify.Remove})
+	}
+
+	for path := range created {
+		b.emit(Event{Name: path, Op: fsnotify.Create})
+	}
+}
+
+// matchRename finds a created entry with the same size and mtime as a
+// removed one, treating it as that entry's rename target.
+func (b *pollingBackend) matchRename(removed os.FileInfo, created map[string]os.FileInfo) (string, bool) {
+	for path, info := range created {
+		if info.Size() == removed.Size() && info.ModTime().Equal(removed.ModTime()) {
+			return path, true
+		}
+	}
+	return "", false
+}
+
+func (b *pollingBackend) emit(ev Event) {
+	select {
+	case b.events <- ev:
+	case <-b.done:
+	}
+}
+
+//-----------------------------------------------------------------------------
@@ -0,0 +1,139 @@
+package dirwatch
+
+import (
+	"io/fs"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// fakeFileInfo is a minimal os.FileInfo for exercising diff/matchRename
+// without touching the filesystem.
+type fakeFileInfo struct {
+	name    string
+	size    int64
+	mode    os.FileMode
+	modTime time.Time
+	isDir   bool
+}
+
+func (f fakeFileInfo) Name() string       { return f.name }
+func (f fakeFileInfo) Size() int64        { return f.size }
+func (f fakeFileInfo) Mode() os.FileMode  { return f.mode }
+func (f fakeFileInfo) ModTime() time.Time { return f.modTime }
+func (f fakeFileInfo) IsDir() bool        { return f.isDir }
+func (f fakeFileInfo) Sys() interface{}   { return nil }
+
+var _ fs.FileInfo = fakeFileInfo{}
+
+func newBackendForDiff() *pollingBackend {
+	return &pollingBackend{
+		events: make(chan Event, 16),
+		errs:   make(chan error, 16),
+		done:   make(chan struct{}),
+	}
+}
+
+func TestPollingBackendDiffCreate(t *testing.T) {
+	b := newBackendForDiff()
+	mtime := time.Now()
+	next := map[string]os.FileInfo{
+		"a": fakeFileInfo{name: "a", size: 1, modTime: mtime},
+	}
+	b.diff(map[string]os.FileInfo{}, next)
+
+	ev := <-b.events
+	if ev.Name != "a" || ev.Op != fsnotify.Create {
+		t.Fatalf("got %+v, want Create a", ev)
+	}
+}
+
+func TestPollingBackendDiffRemove(t *testing.T) {
+	b := newBackendForDiff()
+	mtime := time.Now()
+	prev := map[string]os.FileInfo{
+		"a": fakeFileInfo{name: "a", size: 1, modTime: mtime},
+	}
+	b.diff(prev, map[string]os.FileInfo{})
+
+	ev := <-b.events
+	if ev.Name != "a" || ev.Op != fsnotify.Remove {
+		t.Fatalf("got %+v, want Remove a", ev)
+	}
+}
+
+func TestPollingBackendDiffWrite(t *testing.T) {
+	b := newBackendForDiff()
+	prev := map[string]os.FileInfo{
+		"a": fakeFileInfo{name: "a", size: 1, modTime: time.Unix(0, 0)},
+	}
+	next := map[string]os.FileInfo{
+		"a": fakeFileInfo{name: "a", size: 2, modTime: time.Unix(1, 0)},
+	}
+	b.diff(prev, next)
+
+	ev := <-b.events
+	if ev.Name != "a" || ev.Op != fsnotify.Write {
+		t.Fatalf("got %+v, want Write a", ev)
+	}
+}
+
+func TestPollingBackendDiffChmod(t *testing.T) {
+	b := newBackendForDiff()
+	mtime := time.Unix(0, 0)
+	prev := map[string]os.FileInfo{
+		"a": fakeFileInfo{name: "a", size: 1, mode: 0o644, modTime: mtime},
+	}
+	next := map[string]os.FileInfo{
+		"a": fakeFileInfo{name: "a", size: 1, mode: 0o600, modTime: mtime},
+	}
+	b.diff(prev, next)
+
+	ev := <-b.events
+	if ev.Name != "a" || ev.Op != fsnotify.Chmod {
+		t.Fatalf("got %+v, want Chmod a", ev)
+	}
+}
+
+func TestPollingBackendDiffRename(t *testing.T) {
+	b := newBackendForDiff()
+	mtime := time.Unix(0, 0)
+	prev := map[string]os.FileInfo{
+		"old": fakeFileInfo{name: "old", size: 5, modTime: mtime},
+	}
+	next := map[string]os.FileInfo{
+		"new": fakeFileInfo{name: "new", size: 5, modTime: mtime},
+	}
+	b.diff(prev, next)
+
+	first := <-b.events
+	second := <-b.events
+	if first.Name != "old" || first.Op != fsnotify.Rename {
+		t.Fatalf("got %+v, want Rename old", first)
+	}
+	if second.Name != "new" || second.Op != fsnotify.Create {
+		t.Fatalf("got %+v, want Create new", second)
+	}
+}
+
+func TestPollingBackendMatchRename(t *testing.T) {
+	b := newBackendForDiff()
+	mtime := time.Unix(0, 0)
+	removed := fakeFileInfo{name: "old", size: 5, modTime: mtime}
+	created := map[string]os.FileInfo{
+		"unrelated": fakeFileInfo{name: "unrelated", size: 1, modTime: time.Unix(1, 0)},
+		"new":       fakeFileInfo{name: "new", size: 5, modTime: mtime},
+	}
+
+	got, ok := b.matchRename(removed, created)
+	if !ok || got != "new" {
+		t.Fatalf("matchRename() = %q, %v, want \"new\", true", got, ok)
+	}
+
+	_, ok = b.matchRename(fakeFileInfo{name: "old", size: 99, modTime: mtime}, created)
+	if ok {
+		t.Fatalf("matchRename() matched an entry with a different size")
+	}
+}
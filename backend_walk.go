@@ -0,0 +1,42 @@
+package dirwatch
+
+import (
+	"github.com/fsnotify/fsnotify"
+	"github.com/pkg/errors"
+)
+
+//-----------------------------------------------------------------------------
+
+// walkBackend is the inotify-style fallback: fsnotify has no concept of a
+// recursive watch, so the caller must walk a directory tree and Add every
+// sub-directory individually. This is today's dirwatch behavior, kept as
+// the default on platforms without a native recursive backend.
+type walkBackend struct {
+	w *fsnotify.Watcher
+}
+
+func newWalkBackend() (backend, error) {
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return &walkBackend{w: w}, nil
+}
+
+func (b *walkBackend) Add(path string, recursive bool) error {
+	return b.w.Add(path)
+}
+
+func (b *walkBackend) Remove(path string) error {
+	return b.w.Remove(path)
+}
+
+func (b *walkBackend) Recursive() bool { return false }
+
+func (b *walkBackend) Events() <-chan Event { return b.w.Events }
+
+func (b *walkBackend) Errors() <-chan error { return b.w.Errors }
+
+func (b *walkBackend) Close() error { return b.w.Close() }
+
+//-----------------------------------------------------------------------------
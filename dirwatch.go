@@ -2,8 +2,11 @@ package dirwatch
 
 import (
 	"context"
+	"log/slog"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/dc0d/retry"
@@ -16,17 +19,110 @@ import (
 // Event represents a single file system notification.
 type Event = fsnotify.Event
 
+// Op describes the set of file system operations carried by an Event.
+type Op = fsnotify.Op
+
 //-----------------------------------------------------------------------------
 
+// Options configures a Watcher created through NewWithOptions.
+type Options struct {
+	// Ops restricts delivery to events matching this bitmask. The zero
+	// value matches every operation.
+	Ops Op
+
+	// Notify receives every event that passes Ops and Exclude. It may be
+	// nil if Handlers alone is enough to cover the caller's needs.
+	Notify func(Event)
+
+	// Exclude holds gitignore-style patterns (supporting "**", "!"
+	// negation, and rooted vs unrooted matching) for paths to ignore.
+	// Patterns are evaluated relative to whichever watched root contains
+	// the path.
+	Exclude []string
+
+	// Include, when non-empty, is an allowlist of gitignore-style
+	// patterns: a path must match at least one Include pattern to be
+	// considered at all, before Exclude is applied.
+	Include []string
+
+	// Handlers, when set, fans an event out to a dedicated callback per
+	// operation, in addition to Notify. A handler is invoked whenever
+	// ev.Op.Has(op) holds for its key, so it still fires for combined
+	// events such as Write|Chmod.
+	Handlers map[Op]func(Event)
+
+	// Debounce merges repeated events on the same path arriving within
+	// the window into a single delivered Event. Zero disables debouncing.
+	// A Remove event always flushes and delivers immediately, since a
+	// pending Write on a path that no longer exists is no longer useful.
+	// Each path has its own timer, and callbacks run in their own
+	// goroutines, so the order of delivery across distinct paths is not
+	// defined.
+	Debounce time.Duration
+
+	// Coalesce, when true and Debounce is set, combines the Op bitmask of
+	// every event collapsed within the window instead of keeping only the
+	// most recent one. Editors often emit Chmod+Write+Rename storms on
+	// save; Coalesce lets a single callback see all of them at once.
+	Coalesce bool
+
+	// Backend selects which backend discovers filesystem changes. The
+	// zero value, BackendAuto, picks a native recursive backend on
+	// platforms that provide one and falls back to walking the tree
+	// elsewhere.
+	Backend BackendKind
+
+	// PollInterval sets the tick rate for BackendPolling. Zero uses
+	// defaultPollInterval. Ignored by every other backend.
+	PollInterval time.Duration
+
+	// Symlinks controls whether the recursive walker follows symlinked
+	// directories. The zero value, SymlinkIgnore, never follows them.
+	Symlinks SymlinkMode
+
+	// Logger receives structured log events. Nil uses a Logger backed by
+	// slog.Default(); pass NopLogger to silence it.
+	Logger Logger
+}
+
+// Option mutates an Options value, for callers who prefer assembling
+// configuration through functional options over an Options literal.
+// NewWithOptions applies every Option after opts itself, so an Option always
+// wins over the corresponding field set directly on opts.
+type Option func(*Options)
+
+// WithDebounce sets Options.Debounce.
+func WithDebounce(d time.Duration) Option {
+	return func(o *Options) { o.Debounce = d }
+}
+
+// WithCoalesce sets Options.Coalesce.
+func WithCoalesce(c bool) Option {
+	return func(o *Options) { o.Coalesce = c }
+}
+
+// pendingEvent tracks a debounced event waiting to be flushed for a path.
+type pendingEvent struct {
+	ev    Event
+	timer *time.Timer
+}
+
 // Watcher watches over a directory and it's sub-directories, recursively.
 type Watcher struct {
-	notify  func(Event)
-	exclude []string
+	opts Options
+
+	paths   map[string]bool
+	rootsMu sync.Mutex
+	roots   []string
+	add     chan fspath
+	ctx     context.Context
+	cancel  context.CancelFunc
+
+	logger Logger
+	ignore *ignoreMatcher
 
-	paths  map[string]bool
-	add    chan fspath
-	ctx    context.Context
-	cancel context.CancelFunc
+	pendingMu sync.Mutex
+	pending   map[string]*pendingEvent
 }
 
 type fspath struct {
@@ -40,11 +136,35 @@ func New(notify func(Event), exclude ...string) *Watcher {
 		panic("notify can not be nil")
 	}
 
+	return NewWithOptions(Options{
+		Notify:  notify,
+		Exclude: exclude,
+	})
+}
+
+// NewWithOptions creates a new *Watcher configured by opts, then applies
+// optFns on top of it. Either opts.Notify or opts.Handlers must be
+// provided, whether set directly on opts or through an Option.
+func NewWithOptions(opts Options, optFns ...Option) *Watcher {
+	for _, fn := range optFns {
+		fn(&opts)
+	}
+
+	if opts.Notify == nil && len(opts.Handlers) == 0 {
+		panic("notify can not be nil")
+	}
+
+	logger := opts.Logger
+	if logger == nil {
+		logger = NewSlogLogger(slog.Default())
+	}
+
 	res := &Watcher{
-		add:     make(chan fspath),
-		paths:   make(map[string]bool),
-		notify:  notify,
-		exclude: exclude,
+		add:    make(chan fspath),
+		paths:  make(map[string]bool),
+		opts:   opts,
+		logger: logger,
+		ignore: newIgnoreMatcher(opts.Exclude, opts.Include),
 	}
 	res.ctx, res.cancel = context.WithCancel(context.Background())
 
@@ -55,6 +175,7 @@ func New(notify func(Event), exclude ...string) *Watcher {
 // Stop stops the watcher. Safe to be called mutiple times.
 func (dw *Watcher) Stop() {
 	dw.cancel()
+	dw.flushAllPending()
 }
 
 // Add adds a path to be watched.
@@ -64,7 +185,7 @@ func (dw *Watcher) Add(path string, recursive bool) {
 		close(started)
 		v, err := filepath.Abs(path)
 		if err != nil {
-			lerror(err)
+			dw.logger.Error("resolve absolute path failed", "path", path, "error", err)
 			return
 		}
 		select {
@@ -76,6 +197,13 @@ func (dw *Watcher) Add(path string, recursive bool) {
 	<-started
 }
 
+// AddIgnoreFile loads exclude patterns from a .gitignore/.dockerignore
+// style file at path, on top of any patterns already configured through
+// Options.Exclude.
+func (dw *Watcher) AddIgnoreFile(path string) error {
+	return dw.ignore.addExcludeFile(path)
+}
+
 //-----------------------------------------------------------------------------
 
 func (dw *Watcher) stopped() <-chan struct{} { return dw.ctx.Done() }
@@ -87,7 +215,7 @@ func (dw *Watcher) start() {
 		retry.Retry(
 			dw.agent,
 			-1,
-			func(e error) { lerrorf("watcher agent error: %+v", e) },
+			func(e error) { dw.logger.Warn("watcher agent restarting after error", "error", e) },
 			time.Second*5)
 	}()
 	<-started
@@ -96,28 +224,28 @@ func (dw *Watcher) start() {
 }
 
 func (dw *Watcher) agent() error {
-	watcher, err := fsnotify.NewWatcher()
+	b, err := newBackend(dw.opts.Backend, dw.opts.PollInterval, dw.ignore)
 	if err != nil {
 		return errors.WithStack(err)
 	}
-	defer watcher.Close()
+	defer b.Close()
 
 	for {
 		select {
 		case <-dw.stopped():
 			return nil
-		case ev := <-watcher.Events:
+		case ev := <-b.Events():
 			dw.onEvent(ev)
-		case err := <-watcher.Errors:
-			lerrorf("error: %+v\n", errors.WithStack(err))
+		case err := <-b.Errors():
+			dw.logger.Error("backend error", "error", errors.WithStack(err))
 		case d := <-dw.add:
-			dw.onAdd(watcher, d)
+			dw.onAdd(b, d)
 		}
 	}
 }
 
 func (dw *Watcher) onAdd(
-	watcher *fsnotify.Watcher,
+	b backend,
 	fsp fspath) {
 	if fsp.path == "" {
 		return
@@ -125,7 +253,7 @@ func (dw *Watcher) onAdd(
 	var err error
 	fsp.path, err = filepath.Abs(fsp.path)
 	if err != nil {
-		lerror(err)
+		dw.logger.Error("resolve absolute path failed", "path", fsp.path, "error", err)
 		return
 	}
 	_, err = os.Stat(fsp.path)
@@ -134,7 +262,7 @@ func (dw *Watcher) onAdd(
 			delete(dw.paths, fsp.path)
 			return
 		}
-		lerror(err)
+		dw.logger.Error("stat failed", "path", fsp.path, "error", err)
 		return
 	}
 	recursive, ok := dw.paths[fsp.path]
@@ -144,17 +272,25 @@ func (dw *Watcher) onAdd(
 	if dw.excludePath(fsp.path) {
 		return
 	}
-	if err := watcher.Add(fsp.path); err != nil {
-		lerrorf("on add error: %+v\n", errors.WithStack(err))
+	if fsp.recursive == nil && b.Recursive() {
+		// A recursive backend already covers sub-directories through the
+		// single watch on their root.
+		return
 	}
 	if fsp.recursive != nil {
 		recursive = *fsp.recursive
+		dw.rootsMu.Lock()
+		dw.roots = append(dw.roots, fsp.path)
+		dw.rootsMu.Unlock()
+	}
+	if err := b.Add(fsp.path, recursive); err != nil {
+		dw.logger.Error("add watch failed", "path", fsp.path, "recursive", recursive, "error", errors.WithStack(err))
 	}
 	dw.paths[fsp.path] = recursive
 	isd, _ := isDir(fsp.path)
-	if recursive && isd {
+	if recursive && isd && !b.Recursive() {
 		go func() {
-			tree := dirTree(fsp.path)
+			tree := dw.dirTree(fsp.path)
 			for v := range tree {
 				dw.add <- fspath{path: v}
 			}
@@ -166,8 +302,7 @@ func (dw *Watcher) onEvent(ev Event) {
 	if dw.excludePath(ev.Name) {
 		return
 	}
-	// callback
-	go retry.Try(func() error { dw.notify(ev); return nil })
+	dw.deliver(ev)
 
 	name := ev.Name
 	isdir, err := isDir(name)
@@ -175,7 +310,7 @@ func (dw *Watcher) onEvent(ev Event) {
 		if os.IsNotExist(err) {
 			delete(dw.paths, name)
 		} else {
-			lerror(err)
+			dw.logger.Error("stat failed", "path", name, "error", err)
 		}
 		return
 	}
@@ -193,44 +328,207 @@ func (dw *Watcher) onEvent(ev Event) {
 	}()
 }
 
+// deliver applies the configured debounce/coalesce policy and eventually
+// calls dispatch for ev.
+func (dw *Watcher) deliver(ev Event) {
+	if dw.opts.Debounce <= 0 {
+		dw.dispatch(ev)
+		return
+	}
+
+	if ev.Op.Has(fsnotify.Remove) {
+		dw.flushPending(ev.Name)
+		dw.dispatch(ev)
+		return
+	}
+
+	dw.pendingMu.Lock()
+	if dw.pending == nil {
+		dw.pending = make(map[string]*pendingEvent)
+	}
+	p, ok := dw.pending[ev.Name]
+	if !ok {
+		p = &pendingEvent{ev: ev}
+		dw.pending[ev.Name] = p
+	} else if dw.opts.Coalesce {
+		p.ev.Op |= ev.Op
+	} else {
+		p.ev = ev
+	}
+	if p.timer != nil {
+		p.timer.Stop()
+	}
+	path := ev.Name
+	p.timer = time.AfterFunc(dw.opts.Debounce, func() { dw.flushPending(path) })
+	dw.pendingMu.Unlock()
+}
+
+// flushPending delivers and clears the pending event for path, if any.
+func (dw *Watcher) flushPending(path string) {
+	dw.pendingMu.Lock()
+	p, ok := dw.pending[path]
+	if ok {
+		delete(dw.pending, path)
+	}
+	dw.pendingMu.Unlock()
+	if !ok {
+		return
+	}
+	p.timer.Stop()
+	dw.dispatch(p.ev)
+}
+
+// flushAllPending delivers every pending debounced event immediately. It is
+// called on Stop so no event is silently lost.
+func (dw *Watcher) flushAllPending() {
+	dw.pendingMu.Lock()
+	pending := dw.pending
+	dw.pending = nil
+	dw.pendingMu.Unlock()
+
+	for _, p := range pending {
+		p.timer.Stop()
+		dw.dispatch(p.ev)
+	}
+}
+
+// dispatch filters ev against opts.Ops and fans it out to opts.Handlers and
+// opts.Notify.
+func (dw *Watcher) dispatch(ev Event) {
+	if dw.opts.Ops != 0 && ev.Op&dw.opts.Ops == 0 {
+		return
+	}
+
+	for op, h := range dw.opts.Handlers {
+		if h == nil || !ev.Op.Has(op) {
+			continue
+		}
+		h := h
+		go retry.Try(func() error { h(ev); return nil })
+	}
+
+	if dw.opts.Notify != nil {
+		go retry.Try(func() error { dw.opts.Notify(ev); return nil })
+	}
+}
+
+// excludePath reports whether p should be ignored, evaluating Exclude and
+// Include against p relative to whichever watched root contains it.
 func (dw *Watcher) excludePath(p string) bool {
-	for _, ptrn := range dw.exclude {
-		matched, err := filepath.Match(ptrn, p)
-		if err != nil {
-			lerror(err)
+	rel := p
+	if root := dw.rootFor(p); root != "" {
+		if r, err := filepath.Rel(root, p); err == nil {
+			rel = r
+		}
+	}
+	isd, _ := isDir(p)
+	return dw.ignore.excluded(rel, isd)
+}
+
+// rootFor returns the longest watched root (as registered by the public
+// Add) that is an ancestor of, or equal to, p. It returns "" if p isn't
+// under any known root, in which case p is matched as-is.
+func (dw *Watcher) rootFor(p string) string {
+	dw.rootsMu.Lock()
+	roots := dw.roots
+	dw.rootsMu.Unlock()
+
+	best := ""
+	for _, root := range roots {
+		if root != p && !strings.HasPrefix(p, root+string(filepath.Separator)) {
 			continue
 		}
-		if matched {
-			return true
+		if len(root) > len(best) {
+			best = root
 		}
 	}
-	return false
+	return best
 }
 
-func dirTree(queryRoot string) <-chan string {
+// dirTree walks queryRoot recursively and streams every sub-directory that
+// isn't ignored, never descending into an ignored directory in the first
+// place.
+func (dw *Watcher) dirTree(queryRoot string) <-chan string {
 	found := make(chan string)
 	go func() {
 		defer close(found)
-		err := filepath.Walk(queryRoot, func(path string, f os.FileInfo, err error) error {
-			if !f.IsDir() {
+		dw.walkDir(queryRoot, make(map[string]bool), found)
+	}()
+	return found
+}
+
+// walkDir streams every sub-directory of dir into found. It relies on
+// filepath.WalkDir, which like os.Lstat never follows a symlink on its
+// own, and recurses into a symlinked directory by hand when opts.Symlinks
+// calls for it.
+func (dw *Watcher) walkDir(dir string, visited map[string]bool, found chan<- string) {
+	if dw.opts.Symlinks == SymlinkFollowWithCycleDetect {
+		if key, ok := fileKey(dir); ok {
+			visited[key] = true
+		}
+	}
+	// The trailing separator makes WalkDir's initial Lstat resolve dir when
+	// it is itself a followed symlink, so the walk descends into its target.
+	root := dir + string(filepath.Separator)
+	err := filepath.WalkDir(root, func(path string, entry os.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if path == root {
+			return nil
+		}
+
+		if entry.Type()&os.ModeSymlink != 0 {
+			if dw.opts.Symlinks == SymlinkIgnore {
+				return nil
+			}
+			inf, serr := os.Stat(path)
+			if serr != nil || !inf.IsDir() {
 				return nil
 			}
-			if filepath.Clean(path) == filepath.Clean(queryRoot) {
+			if dw.opts.Symlinks == SymlinkFollowWithCycleDetect {
+				if key, ok := fileKey(path); ok {
+					if visited[key] {
+						return nil
+					}
+					visited[key] = true
+				}
+			}
+			if dw.excludePath(path) {
 				return nil
 			}
 			found <- path
+			dw.walkDir(path, visited, found)
 			return nil
-		})
-		if err != nil {
-			lerrorf("%+v", errors.WithStack(err))
 		}
-	}()
-	return found
+
+		if !entry.IsDir() {
+			return nil
+		}
+		if dw.excludePath(path) {
+			return filepath.SkipDir
+		}
+		if dw.opts.Symlinks == SymlinkFollowWithCycleDetect {
+			if key, ok := fileKey(path); ok {
+				visited[key] = true
+			}
+		}
+		found <- path
+		return nil
+	})
+	if err != nil {
+		dw.logger.Error("walk failed", "path", dir, "error", errors.WithStack(err))
+	}
 }
 
+// isDir reports whether path is a directory. It checks err before
+// dereferencing inf, so a broken symlink or a TOCTOU removal doesn't panic.
 func isDir(path string) (bool, error) {
 	inf, err := os.Stat(path)
-	return inf.IsDir(), err
+	if err != nil {
+		return false, err
+	}
+	return inf.IsDir(), nil
 }
 
 //-----------------------------------------------------------------------------
@@ -0,0 +1,103 @@
+package dirwatch
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// newTestWatcher builds a Watcher whose debounce/dispatch machinery works
+// standalone, without starting the backend agent goroutine.
+func newTestWatcher(opts Options) (*Watcher, chan Event) {
+	delivered := make(chan Event, 16)
+	opts.Notify = func(ev Event) { delivered <- ev }
+	return &Watcher{
+		opts:   opts,
+		paths:  make(map[string]bool),
+		logger: NopLogger,
+		ignore: newIgnoreMatcher(nil, nil),
+	}, delivered
+}
+
+func TestDeliverDebounceCoalescesSamePath(t *testing.T) {
+	dw, delivered := newTestWatcher(Options{Debounce: 20 * time.Millisecond, Coalesce: true})
+
+	dw.deliver(Event{Name: "a", Op: fsnotify.Write})
+	dw.deliver(Event{Name: "a", Op: fsnotify.Chmod})
+
+	select {
+	case ev := <-delivered:
+		if ev.Op != fsnotify.Write|fsnotify.Chmod {
+			t.Fatalf("got Op %v, want Write|Chmod", ev.Op)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for coalesced event")
+	}
+}
+
+func TestDeliverDebounceWithoutCoalesceKeepsLatest(t *testing.T) {
+	dw, delivered := newTestWatcher(Options{Debounce: 20 * time.Millisecond})
+
+	dw.deliver(Event{Name: "a", Op: fsnotify.Write})
+	dw.deliver(Event{Name: "a", Op: fsnotify.Chmod})
+
+	select {
+	case ev := <-delivered:
+		if ev.Op != fsnotify.Chmod {
+			t.Fatalf("got Op %v, want Chmod", ev.Op)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for debounced event")
+	}
+}
+
+func TestDeliverDebounceDeliversEachPathSeparately(t *testing.T) {
+	dw, delivered := newTestWatcher(Options{Debounce: 20 * time.Millisecond})
+
+	var wg sync.WaitGroup
+	for i, name := range []string{"a", "b", "c"} {
+		wg.Add(1)
+		go func(i int, name string) {
+			defer wg.Done()
+			dw.deliver(Event{Name: name, Op: fsnotify.Write})
+		}(i, name)
+	}
+	wg.Wait()
+
+	seen := make(map[string]bool)
+	for i := 0; i < 3; i++ {
+		select {
+		case ev := <-delivered:
+			seen[ev.Name] = true
+		case <-time.After(time.Second):
+			t.Fatal("timed out waiting for per-path event")
+		}
+	}
+	for _, name := range []string{"a", "b", "c"} {
+		if !seen[name] {
+			t.Fatalf("missing delivered event for %q", name)
+		}
+	}
+}
+
+func TestDeliverRemoveFlushesPendingImmediately(t *testing.T) {
+	dw, delivered := newTestWatcher(Options{Debounce: time.Hour})
+
+	dw.deliver(Event{Name: "a", Op: fsnotify.Write})
+	dw.deliver(Event{Name: "a", Op: fsnotify.Remove})
+
+	seenOps := make(map[fsnotify.Op]bool)
+	for i := 0; i < 2; i++ {
+		select {
+		case ev := <-delivered:
+			seenOps[ev.Op] = true
+		case <-time.After(time.Second):
+			t.Fatal("timed out waiting for flushed events")
+		}
+	}
+	if !seenOps[fsnotify.Write] || !seenOps[fsnotify.Remove] {
+		t.Fatalf("got ops %v, want both Write and Remove delivered without waiting for the hour-long debounce", seenOps)
+	}
+}
@@ -0,0 +1,224 @@
+package dirwatch
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+//-----------------------------------------------------------------------------
+
+// ignorePattern is a single compiled line from an Exclude/Include list or a
+// loaded ignore file, following gitignore syntax: a leading "!" negates the
+// pattern, a trailing "/" restricts it to directories, and a pattern
+// containing an interior "/" (or a leading one) is rooted to where it was
+// declared rather than matching at any depth. "**" matches zero or more
+// path segments.
+type ignorePattern struct {
+	negate   bool
+	dirOnly  bool
+	anchored bool
+	segments []string
+}
+
+// compilePattern parses a single gitignore-style line. ok is false for blank
+// lines and comments, which carry no pattern.
+func compilePattern(line string) (p ignorePattern, ok bool) {
+	line = strings.TrimRight(line, " \t")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return ignorePattern{}, false
+	}
+
+	if strings.HasPrefix(line, "!") {
+		p.negate = true
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		p.dirOnly = true
+		line = strings.TrimSuffix(line, "/")
+	}
+	if strings.HasPrefix(line, "/") {
+		line = strings.TrimPrefix(line, "/")
+		p.anchored = true
+	}
+	if line == "" {
+		return ignorePattern{}, false
+	}
+	if strings.Contains(line, "/") {
+		p.anchored = true
+	}
+
+	p.segments = strings.Split(line, "/")
+	return p, true
+}
+
+// match reports whether rel, a slash-separated path relative to a watched
+// root, matches the pattern. isDir tells whether rel names a directory.
+func (p ignorePattern) match(rel string, isDir bool) bool {
+	if p.dirOnly && !isDir {
+		return false
+	}
+	if rel == "" {
+		return false
+	}
+
+	segs := strings.Split(rel, "/")
+	if p.anchored {
+		return matchSegments(p.segments, segs)
+	}
+	for i := range segs {
+		if matchSegments(p.segments, segs[i:]) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchSegments matches pattern path segments against rel path segments,
+// where a "**" segment in pattern matches zero or more segments of path.
+func matchSegments(pattern, path []string) bool {
+	if len(pattern) == 0 {
+		return len(path) == 0
+	}
+	if pattern[0] == "**" {
+		if matchSegments(pattern[1:], path) {
+			return true
+		}
+		if len(path) == 0 {
+			return false
+		}
+		return matchSegments(pattern, path[1:])
+	}
+	if len(path) == 0 {
+		return false
+	}
+	ok, err := filepath.Match(pattern[0], path[0])
+	if err != nil || !ok {
+		return false
+	}
+	return matchSegments(pattern[1:], path[1:])
+}
+
+//-----------------------------------------------------------------------------
+
+// ignoreMatcher evaluates gitignore-style Include/Exclude patterns against
+// paths relative to a watched root. It is safe for concurrent use, since
+// patterns can be appended at runtime via AddIgnoreFile while the agent
+// goroutine is evaluating events.
+type ignoreMatcher struct {
+	mu      sync.RWMutex
+	exclude []ignorePattern
+	include []ignorePattern
+}
+
+// newIgnoreMatcher compiles the initial exclude and include pattern lists.
+func newIgnoreMatcher(exclude, include []string) *ignoreMatcher {
+	m := &ignoreMatcher{}
+	for _, ptrn := range exclude {
+		m.addExclude(ptrn)
+	}
+	for _, ptrn := range include {
+		m.addInclude(ptrn)
+	}
+	return m
+}
+
+func (m *ignoreMatcher) addExclude(pattern string) {
+	p, ok := compilePattern(pattern)
+	if !ok {
+		return
+	}
+	m.mu.Lock()
+	m.exclude = append(m.exclude, p)
+	m.mu.Unlock()
+}
+
+func (m *ignoreMatcher) addInclude(pattern string) {
+	p, ok := compilePattern(pattern)
+	if !ok {
+		return
+	}
+	m.mu.Lock()
+	m.include = append(m.include, p)
+	m.mu.Unlock()
+}
+
+// addExcludeFile loads newline-delimited exclude patterns from a
+// .gitignore/.dockerignore style file.
+func (m *ignoreMatcher) addExcludeFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		m.addExclude(scanner.Text())
+	}
+	return errors.WithStack(scanner.Err())
+}
+
+// excluded reports whether rel (relative to a watched root) should be
+// skipped. When Include patterns are configured, rel must match one of them
+// to survive at all; it is then subject to Exclude, where, as in gitignore,
+// the last matching pattern wins and a "!" pattern can negate an earlier
+// match.
+func (m *ignoreMatcher) excluded(rel string, isDir bool) bool {
+	rel = filepath.ToSlash(rel)
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if len(m.include) > 0 {
+		included := false
+		for _, p := range m.include {
+			if p.match(rel, isDir) {
+				included = !p.negate
+			}
+		}
+		if !included {
+			return true
+		}
+	}
+
+	if m.excludedAncestor(rel) {
+		return true
+	}
+
+	excluded := false
+	for _, p := range m.exclude {
+		if p.match(rel, isDir) {
+			excluded = !p.negate
+		}
+	}
+	return excluded
+}
+
+// excludedAncestor reports whether any directory ancestor of rel matches an
+// exclude pattern. Like gitignore, excluding a directory excludes
+// everything under it: a watcher never descends into an ignored directory,
+// so a pattern matching only "node_modules" must still apply to
+// "node_modules/foo.js". Callers must hold at least a read lock.
+func (m *ignoreMatcher) excludedAncestor(rel string) bool {
+	segs := strings.Split(rel, "/")
+	for i := 1; i < len(segs); i++ {
+		anc := strings.Join(segs[:i], "/")
+		excluded := false
+		for _, p := range m.exclude {
+			if p.match(anc, true) {
+				excluded = !p.negate
+			}
+		}
+		if excluded {
+			return true
+		}
+	}
+	return false
+}
+
+//-----------------------------------------------------------------------------
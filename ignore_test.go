@@ -0,0 +1,108 @@
+package dirwatch
+
+import "testing"
+
+func TestIgnoreMatcherExcluded(t *testing.T) {
+	tests := []struct {
+		name    string
+		exclude []string
+		include []string
+		rel     string
+		isDir   bool
+		want    bool
+	}{
+		{
+			name:  "no patterns",
+			rel:   "main.go",
+			isDir: false,
+			want:  false,
+		},
+		{
+			name:    "plain file match",
+			exclude: []string{"*.log"},
+			rel:     "debug.log",
+			want:    true,
+		},
+		{
+			name:    "descendant of excluded directory",
+			exclude: []string{"node_modules"},
+			rel:     "node_modules/foo.js",
+			want:    true,
+		},
+		{
+			name:    "nested descendant of excluded directory",
+			exclude: []string{"node_modules"},
+			rel:     "node_modules/.bin/cmd",
+			want:    true,
+		},
+		{
+			name:    "directory-only pattern excludes descendants",
+			exclude: []string{"build/"},
+			rel:     "build/output.bin",
+			want:    true,
+		},
+		{
+			name:    "sibling of excluded directory is untouched",
+			exclude: []string{"node_modules"},
+			rel:     "src/node_modules_helper.go",
+			want:    false,
+		},
+		{
+			name:    "globstar match",
+			exclude: []string{"**/*.tmp"},
+			rel:     "a/b/c.tmp",
+			want:    true,
+		},
+		{
+			name:    "negated pattern re-includes a file",
+			exclude: []string{"*.log", "!keep.log"},
+			rel:     "keep.log",
+			want:    false,
+		},
+		{
+			name:    "include allowlist rejects unmatched path",
+			include: []string{"*.go"},
+			rel:     "README.md",
+			want:    true,
+		},
+		{
+			name:    "include allowlist admits matched path",
+			include: []string{"*.go"},
+			rel:     "main.go",
+			want:    false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newIgnoreMatcher(tt.exclude, tt.include)
+			if got := m.excluded(tt.rel, tt.isDir); got != tt.want {
+				t.Errorf("excluded(%q, %v) = %v, want %v", tt.rel, tt.isDir, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchSegments(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern []string
+		path    []string
+		want    bool
+	}{
+		{name: "exact match", pattern: []string{"a", "b"}, path: []string{"a", "b"}, want: true},
+		{name: "pattern longer than path", pattern: []string{"a", "b"}, path: []string{"a"}, want: false},
+		{name: "path longer than pattern", pattern: []string{"a"}, path: []string{"a", "b"}, want: false},
+		{name: "globstar matches zero segments", pattern: []string{"**", "b"}, path: []string{"b"}, want: true},
+		{name: "globstar matches many segments", pattern: []string{"**", "b"}, path: []string{"x", "y", "b"}, want: true},
+		{name: "glob matches one segment", pattern: []string{"*.go"}, path: []string{"main.go"}, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := matchSegments(tt.pattern, tt.path); got != tt.want {
+				t.Errorf("matchSegments(%v, %v) = %v, want %v", tt.pattern, tt.path, got, tt.want)
+			}
+		})
+	}
+}
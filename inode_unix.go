@@ -0,0 +1,28 @@
+//go:build !windows
+
+package dirwatch
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+//-----------------------------------------------------------------------------
+
+// fileKey returns a string uniquely identifying the file at path on this
+// device, for symlink cycle detection, derived from its device and inode
+// number.
+func fileKey(path string) (string, bool) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", false
+	}
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return "", false
+	}
+	return fmt.Sprintf("%d:%d", stat.Dev, stat.Ino), true
+}
+
+//-----------------------------------------------------------------------------
@@ -0,0 +1,41 @@
+//go:build windows
+
+package dirwatch
+
+import (
+	"fmt"
+
+	"golang.org/x/sys/windows"
+)
+
+//-----------------------------------------------------------------------------
+
+// fileKey returns a string uniquely identifying the file at path on this
+// volume, for symlink cycle detection, derived from GetFileInformationByHandle's
+// volume serial number and file index.
+func fileKey(path string) (string, bool) {
+	pathPtr, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return "", false
+	}
+	h, err := windows.CreateFile(
+		pathPtr,
+		0,
+		windows.FILE_SHARE_READ|windows.FILE_SHARE_WRITE|windows.FILE_SHARE_DELETE,
+		nil,
+		windows.OPEN_EXISTING,
+		windows.FILE_FLAG_BACKUP_SEMANTICS,
+		0)
+	if err != nil {
+		return "", false
+	}
+	defer windows.CloseHandle(h)
+
+	var info windows.ByHandleFileInformation
+	if err := windows.GetFileInformationByHandle(h, &info); err != nil {
+		return "", false
+	}
+	return fmt.Sprintf("%d:%d:%d", info.VolumeSerialNumber, info.FileIndexHigh, info.FileIndexLow), true
+}
+
+//-----------------------------------------------------------------------------
@@ -0,0 +1,45 @@
+package dirwatch
+
+import "log/slog"
+
+//-----------------------------------------------------------------------------
+
+// Logger receives structured log events from a Watcher. Its shape matches
+// log/slog's leveled methods: a message followed by alternating key/value
+// pairs, so a *slog.Logger satisfies it once adapted through NewSlogLogger.
+type Logger interface {
+	Debug(msg string, kv ...interface{})
+	Info(msg string, kv ...interface{})
+	Warn(msg string, kv ...interface{})
+	Error(msg string, kv ...interface{})
+}
+
+// NewSlogLogger adapts l to Logger.
+func NewSlogLogger(l *slog.Logger) Logger {
+	return slogLogger{l: l}
+}
+
+type slogLogger struct{ l *slog.Logger }
+
+func (s slogLogger) Debug(msg string, kv ...interface{}) { s.l.Debug(msg, kv...) }
+func (s slogLogger) Info(msg string, kv ...interface{})  { s.l.Info(msg, kv...) }
+func (s slogLogger) Warn(msg string, kv ...interface{})  { s.l.Warn(msg, kv...) }
+func (s slogLogger) Error(msg string, kv ...interface{}) { s.l.Error(msg, kv...) }
+
+// WithLogger sets Options.Logger.
+func WithLogger(l Logger) Option {
+	return func(o *Options) { o.Logger = l }
+}
+
+// NopLogger discards every log event. Set Options.Logger to NopLogger to
+// opt out of logging entirely, rather than route it anywhere.
+var NopLogger Logger = nopLogger{}
+
+type nopLogger struct{}
+
+func (nopLogger) Debug(string, ...interface{}) {}
+func (nopLogger) Info(string, ...interface{})  {}
+func (nopLogger) Warn(string, ...interface{})  {}
+func (nopLogger) Error(string, ...interface{}) {}
+
+//-----------------------------------------------------------------------------
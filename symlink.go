@@ -0,0 +1,22 @@
+package dirwatch
+
+//-----------------------------------------------------------------------------
+
+// SymlinkMode controls how the recursive walker treats symbolic links to
+// directories.
+type SymlinkMode int
+
+const (
+	// SymlinkIgnore never follows a symlink. This is the zero value and
+	// matches filepath.Walk's historical behavior.
+	SymlinkIgnore SymlinkMode = iota
+	// SymlinkFollow follows a symlink into the directory it points to.
+	SymlinkFollow
+	// SymlinkFollowWithCycleDetect follows symlinks like SymlinkFollow,
+	// but tracks every directory visited by device+inode (by volume
+	// serial number and file index on Windows) so a symlink cycle is
+	// only ever descended into once.
+	SymlinkFollowWithCycleDetect
+)
+
+//-----------------------------------------------------------------------------